@@ -3,16 +3,260 @@ package pglocker
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"hash/crc32"
+	"hash/fnv"
+	"log"
+	"math"
+	"math/rand"
+	"strings"
 	"time"
 )
 
 const defaultPingInterval = 10 * time.Second
 
+// holderLogInterval is how often waitForLock re-logs who currently holds a
+// contended lock while it blocks.
+const holderLogInterval = 5 * time.Second
+
 type lockOpts struct {
-	timeout      time.Duration
-	pingInterval time.Duration
+	timeout         time.Duration
+	pingInterval    time.Duration
+	applicationName string
+	mode            Mode
+	modeSet         bool
+	backoff         BackoffStrategy
+	waitCallback    func(attempt int, elapsed time.Duration)
+	keyDeriver      func(string) int64
+	reacquire       *ReacquirePolicy
+}
+
+// ReacquireEvent reports a step in the lifecycle of reacquiring a lock after
+// its connection is lost. See WithReacquire.
+type ReacquireEvent int
+
+const (
+	// EventLost fires when the ping connection holding a lock has died.
+	EventLost ReacquireEvent = iota
+	// EventReacquiring fires before each attempt to reacquire the lock on a
+	// new connection.
+	EventReacquiring
+	// EventReacquired fires once the lock has been reacquired.
+	EventReacquired
+	// EventGaveUp fires when ctx is canceled or ReacquirePolicy.Timeout
+	// elapses before the lock could be reacquired.
+	EventGaveUp
+)
+
+func (e ReacquireEvent) String() string {
+	switch e {
+	case EventLost:
+		return "lost"
+	case EventReacquiring:
+		return "reacquiring"
+	case EventReacquired:
+		return "reacquired"
+	case EventGaveUp:
+		return "gave up"
+	default:
+		return "unknown"
+	}
+}
+
+// ReacquirePolicy configures WithReacquire: what to do when Lock's
+// connection dies and the session-scoped advisory lock it held is gone.
+type ReacquirePolicy struct {
+	// Backoff controls the delay between reacquire attempts. Defaults to
+	// ConstantBackoff{Delay: time.Second} if unset.
+	Backoff BackoffStrategy
+	// Timeout bounds how long to keep trying to reacquire the lock before
+	// giving up. Zero means keep retrying until ctx is canceled.
+	Timeout time.Duration
+	// OnEvent, if set, is called for each ReacquireEvent as reacquisition
+	// proceeds. It's called from the lock's background goroutine, so it
+	// should return quickly.
+	OnEvent func(ReacquireEvent)
+}
+
+// WithReacquire makes Lock (and LockByID, LockByKeys) survive losing its
+// connection: pg_advisory_lock is session-scoped, so a dropped connection
+// (network blip, PgBouncer reset, backend restart) silently releases the
+// lock. With WithReacquire set, a failed ping closes the dead connection,
+// opens a new one from the same *sql.DB, and retries taking the lock
+// according to policy before surrendering.
+func WithReacquire(policy ReacquirePolicy) LockOption {
+	return func(o *lockOpts) {
+		o.reacquire = &policy
+	}
+}
+
+// BackoffStrategy returns how long to wait before the next attempt to take a
+// lock, given the zero-based number of the attempt that just failed (0 after
+// the first failed attempt). It's used with
+// WithBackoff to poll pg_try_advisory_lock instead of blocking on
+// pg_advisory_lock.
+type BackoffStrategy interface {
+	Next(attempt int) time.Duration
+}
+
+// ConstantBackoff waits the same Delay before every attempt.
+type ConstantBackoff struct {
+	Delay time.Duration
+}
+
+// Next implements BackoffStrategy.
+func (b ConstantBackoff) Next(int) time.Duration {
+	return b.Delay
+}
+
+// ExponentialBackoff doubles its delay on every attempt, starting at Base and
+// never exceeding Max. A Max of 0 means no cap.
+type ExponentialBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Next implements BackoffStrategy.
+func (b ExponentialBackoff) Next(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	// time.Duration is an int64, so any more than 62 doublings overflows
+	// regardless of Base; clamp attempt before shifting instead of only
+	// checking the result afterward.
+	const maxAttempt = 62
+	if attempt > maxAttempt {
+		attempt = maxAttempt
+	}
+	delay := b.Base << uint(attempt) //nolint:gosec
+	if delay < 0 || delay>>uint(attempt) != b.Base {
+		delay = math.MaxInt64
+	}
+	if b.Max > 0 && (delay > b.Max || delay < 0) {
+		delay = b.Max
+	}
+	return delay
+}
+
+// JitteredBackoff wraps another BackoffStrategy and randomizes its delay,
+// returning a value in [0, Strategy.Next(attempt)). This spreads out waiters
+// that would otherwise retry in lockstep.
+type JitteredBackoff struct {
+	Strategy BackoffStrategy
+}
+
+// Next implements BackoffStrategy.
+func (b JitteredBackoff) Next(attempt int) time.Duration {
+	delay := b.Strategy.Next(attempt)
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay))) //nolint:gosec
+}
+
+// WithBackoff configures Lock to poll pg_try_advisory_lock using strategy
+// instead of making a single blocking pg_advisory_lock call. Combine with
+// WithTimeout to bound the total wait; without WithTimeout, Lock polls until
+// ctx is canceled.
+func WithBackoff(strategy BackoffStrategy) LockOption {
+	return func(o *lockOpts) {
+		o.backoff = strategy
+	}
+}
+
+// WithWaitCallback registers a callback invoked before each retry while
+// WithBackoff is waiting for a lock, reporting the attempt number (starting
+// at 0) and how long Lock has been waiting so far.
+func WithWaitCallback(callback func(attempt int, elapsed time.Duration)) LockOption {
+	return func(o *lockOpts) {
+		o.waitCallback = callback
+	}
+}
+
+// Mode selects which flavor of PostgreSQL advisory lock to take.
+type Mode int
+
+const (
+	// ModeExclusive is a session-level exclusive lock (pg_advisory_lock). It is
+	// the default for Lock, LockByID, and LockByKeys.
+	ModeExclusive Mode = iota
+	// ModeShared is a session-level shared lock (pg_advisory_lock_shared).
+	// Any number of shared holders can hold the lock concurrently, but an
+	// exclusive holder must wait for all of them to release it.
+	ModeShared
+	// ModeExclusiveTx is a transaction-level exclusive lock
+	// (pg_advisory_xact_lock), valid only with LockTx.
+	ModeExclusiveTx
+	// ModeSharedTx is a transaction-level shared lock
+	// (pg_advisory_xact_lock_shared), valid only with LockTx.
+	ModeSharedTx
+)
+
+// WithMode sets which flavor of advisory lock to take. Lock, LockByID, and
+// LockByKeys accept ModeExclusive (the default) and ModeShared. LockTx
+// accepts ModeExclusiveTx (the default) and ModeSharedTx.
+func WithMode(mode Mode) LockOption {
+	return func(o *lockOpts) {
+		o.mode = mode
+		o.modeSet = true
+	}
+}
+
+// WithKeyDeriver overrides how Lock turns a lock name into the int64 key
+// passed to pg_advisory_lock. The default hashes the name with 64-bit FNV-1a.
+// Use this to plug in a registry of well-known keys (e.g. a set of
+// pre-declared constants shared across services) instead of hashing names.
+func WithKeyDeriver(deriver func(string) int64) LockOption {
+	return func(o *lockOpts) {
+		o.keyDeriver = deriver
+	}
+}
+
+type modeQueries struct {
+	tryLock      string
+	blockingLock string
+	unlock       string
+}
+
+// sessionModeQueries are used by Lock and LockByID, which identify a lock by
+// a single bigint key.
+var sessionModeQueries = map[Mode]modeQueries{
+	ModeExclusive: {
+		tryLock:      "SELECT pg_try_advisory_lock($1)",
+		blockingLock: "SELECT pg_advisory_lock($1)",
+		unlock:       "SELECT pg_advisory_unlock($1)",
+	},
+	ModeShared: {
+		tryLock:      "SELECT pg_try_advisory_lock_shared($1)",
+		blockingLock: "SELECT pg_advisory_lock_shared($1)",
+		unlock:       "SELECT pg_advisory_unlock_shared($1)",
+	},
+}
+
+// sessionModeQueriesTwoKey are used by LockByKeys, which identifies a lock by
+// two int32 keys.
+var sessionModeQueriesTwoKey = map[Mode]modeQueries{
+	ModeExclusive: {
+		tryLock:      "SELECT pg_try_advisory_lock($1, $2)",
+		blockingLock: "SELECT pg_advisory_lock($1, $2)",
+		unlock:       "SELECT pg_advisory_unlock($1, $2)",
+	},
+	ModeShared: {
+		tryLock:      "SELECT pg_try_advisory_lock_shared($1, $2)",
+		blockingLock: "SELECT pg_advisory_lock_shared($1, $2)",
+		unlock:       "SELECT pg_advisory_unlock_shared($1, $2)",
+	},
+}
+
+var txModeQueries = map[Mode]modeQueries{
+	ModeExclusiveTx: {
+		tryLock:      "SELECT pg_try_advisory_xact_lock($1)",
+		blockingLock: "SELECT pg_advisory_xact_lock($1)",
+	},
+	ModeSharedTx: {
+		tryLock:      "SELECT pg_try_advisory_xact_lock_shared($1)",
+		blockingLock: "SELECT pg_advisory_xact_lock_shared($1)",
+	},
 }
 
 // LockOption is an optional value for Lock
@@ -33,27 +277,197 @@ func WithPingInterval(pingInterval time.Duration) LockOption {
 	}
 }
 
+// WithApplicationName sets application_name on the connection Lock acquires,
+// before it attempts to take the lock. This lets LockHolders (and anyone
+// else looking at pg_stat_activity) identify who is holding a lock.
+func WithApplicationName(applicationName string) LockOption {
+	return func(o *lockOpts) {
+		o.applicationName = applicationName
+	}
+}
+
+// Holder describes a session holding a pglocker advisory lock, as reported by
+// pg_locks and pg_stat_activity.
+type Holder struct {
+	PID             int
+	ApplicationName string
+	ClientAddr      string
+	ClientPort      int
+	BackendStart    time.Time
+	Query           string
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("pid=%d application_name=%q client=%s:%d", h.PID, h.ApplicationName, h.ClientAddr, h.ClientPort)
+}
+
+// singleKeyObjSubID and twoKeyObjSubID are the pg_locks.objsubid values
+// postgres records for the single-bigint and two-int32 forms of
+// pg_advisory_lock, respectively. Filtering on objsubid keeps a lock taken
+// with one form from cross-reporting as a holder of the other form when
+// their (classid, objid) happen to collide.
+const (
+	singleKeyObjSubID int32 = 1
+	twoKeyObjSubID    int32 = 2
+)
+
+const lockHoldersQuery = `
+SELECT
+	pg_stat_activity.pid,
+	pg_stat_activity.application_name,
+	pg_stat_activity.client_addr,
+	pg_stat_activity.client_port,
+	pg_stat_activity.backend_start,
+	pg_stat_activity.query
+FROM pg_locks
+JOIN pg_stat_activity ON pg_stat_activity.pid = pg_locks.pid
+WHERE pg_locks.locktype = 'advisory'
+  AND pg_locks.classid = $1
+  AND pg_locks.objid = $2
+  AND pg_locks.objsubid = $3
+  AND pg_locks.granted
+`
+
+// queryer is satisfied by *sql.DB, *sql.Conn, and *sql.Tx. It lets
+// lockHoldersByKey report on a lock's holders regardless of whether the
+// caller is working with a pooled connection or a transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// LockHolders queries pg_locks and pg_stat_activity for sessions currently
+// holding the advisory lock identified by lockName; sessions still waiting
+// for it are not reported. It's
+// meant for diagnosing "who has the lock?" when Lock fails or is taking a
+// long time. It assumes lockName is keyed with the default name-to-key
+// hash; for a lock taken with WithKeyDeriver, LockByID, or LockByKeys, use
+// LockHoldersByID or LockHoldersByKeys instead.
+func LockHolders(ctx context.Context, db *sql.DB, lockName string) ([]Holder, error) {
+	classid, objid := splitKey(defaultKeyDeriver(lockName))
+	return lockHoldersByKey(ctx, db, classid, objid, singleKeyObjSubID)
+}
+
+// LockHoldersByID is LockHolders for a lock taken with LockByID (or with Lock
+// and a custom WithKeyDeriver).
+func LockHoldersByID(ctx context.Context, db *sql.DB, id int64) ([]Holder, error) {
+	classid, objid := splitKey(id)
+	return lockHoldersByKey(ctx, db, classid, objid, singleKeyObjSubID)
+}
+
+// LockHoldersByKeys is LockHolders for a lock taken with LockByKeys.
+func LockHoldersByKeys(ctx context.Context, db *sql.DB, key1, key2 int32) ([]Holder, error) {
+	return lockHoldersByKey(ctx, db, key1, key2, twoKeyObjSubID)
+}
+
+func lockHoldersByKey(ctx context.Context, q queryer, classid, objid, objsubid int32) ([]Holder, error) {
+	rows, err := q.QueryContext(ctx, lockHoldersQuery, classid, objid, objsubid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var holders []Holder
+	for rows.Next() {
+		var (
+			h          Holder
+			clientAddr sql.NullString
+			clientPort sql.NullInt64
+			query      sql.NullString
+		)
+		err = rows.Scan(&h.PID, &h.ApplicationName, &clientAddr, &clientPort, &h.BackendStart, &query)
+		if err != nil {
+			return nil, err
+		}
+		h.ClientAddr = clientAddr.String
+		h.ClientPort = int(clientPort.Int64)
+		h.Query = query.String
+		holders = append(holders, h)
+	}
+	return holders, rows.Err()
+}
+
 // Lock gets an advisory lock from postgres and holds it until ctx is canceled.
 // It pings the db connection at a regular interval to keep it from timing out.
 // If the lock is unavailable and "WithTimeout" is set, it will continue trying until it either times out or obtains a lock.
 // Returns an error channel that will receive an error when the lock is released.
 func Lock(ctx context.Context, db *sql.DB, lockName string, options ...LockOption) (<-chan error, error) {
+	opts := newLockOpts(options)
+	if err := checkSessionMode(opts.mode); err != nil {
+		return nil, err
+	}
+	id := deriveKey(opts, lockName)
+	classid, objid := splitKey(id)
+	label := fmt.Sprintf("%q", lockName)
+	return lockSession(ctx, db, label, sessionModeQueries[opts.mode], classid, objid, singleKeyObjSubID, []interface{}{id}, opts)
+}
+
+// LockByID gets an advisory lock on id directly, bypassing name hashing. It
+// uses the single-bigint form of pg_advisory_lock, giving access to the full
+// 64-bit advisory-lock keyspace. Options and behavior otherwise match Lock.
+func LockByID(ctx context.Context, db *sql.DB, id int64, options ...LockOption) (<-chan error, error) {
+	opts := newLockOpts(options)
+	if err := checkSessionMode(opts.mode); err != nil {
+		return nil, err
+	}
+	classid, objid := splitKey(id)
+	label := fmt.Sprintf("id=%d", id)
+	return lockSession(ctx, db, label, sessionModeQueries[opts.mode], classid, objid, singleKeyObjSubID, []interface{}{id}, opts)
+}
+
+// LockByKeys gets an advisory lock on the pair (key1, key2) using the
+// two-int form of pg_advisory_lock. This is useful for namespacing, e.g.
+// key1 identifying a tenant and key2 a resource within it. Options and
+// behavior otherwise match Lock.
+func LockByKeys(ctx context.Context, db *sql.DB, key1, key2 int32, options ...LockOption) (<-chan error, error) {
+	opts := newLockOpts(options)
+	if err := checkSessionMode(opts.mode); err != nil {
+		return nil, err
+	}
+	label := fmt.Sprintf("keys=(%d, %d)", key1, key2)
+	return lockSession(ctx, db, label, sessionModeQueriesTwoKey[opts.mode], key1, key2, twoKeyObjSubID, []interface{}{key1, key2}, opts)
+}
+
+func newLockOpts(options []LockOption) *lockOpts {
 	opts := &lockOpts{
 		pingInterval: defaultPingInterval,
 	}
 	for _, o := range options {
 		o(opts)
 	}
+	if !opts.modeSet {
+		opts.mode = ModeExclusive
+	}
+	return opts
+}
+
+func checkSessionMode(mode Mode) error {
+	if mode != ModeExclusive && mode != ModeShared {
+		return fmt.Errorf("mode %v is not valid here, use LockTx for transaction-scoped modes", mode)
+	}
+	return nil
+}
+
+// lockSession implements Lock, LockByID, and LockByKeys: it acquires a
+// session-level advisory lock identified by (classid, objid) using queries
+// bound to args, then pings the connection until ctx is canceled.
+func lockSession(ctx context.Context, db *sql.DB, label string, queries modeQueries, classid, objid, objsubid int32, args []interface{}, opts *lockOpts) (<-chan error, error) {
 	conn, err := db.Conn(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	ok, err := getLock(ctx, conn, lockName, opts.timeout)
+	if opts.applicationName != "" {
+		_, err = conn.ExecContext(ctx, "SELECT set_config('application_name', $1, false)", opts.applicationName)
+		if err != nil {
+			_ = conn.Close() //nolint:errcheck
+			return nil, fmt.Errorf("setting application_name: %w", err)
+		}
+	}
+
+	ok, err := getLock(ctx, conn, db, label, classid, objid, objsubid, queries, args, opts)
 	if err != nil || !ok {
 		_ = conn.Close() //nolint:errcheck
-		err = fmt.Errorf("could not obtain lock: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("could not obtain lock: %w", lockFailureErr(ctx, db, classid, objid, objsubid, err))
 	}
 	errs := make(chan error, 1)
 
@@ -66,11 +480,25 @@ func Lock(ctx context.Context, db *sql.DB, lockName string, options ...LockOptio
 			select {
 			case <-ctx.Done():
 				lErr = ctx.Err()
+				continue
 			case <-ticker.C:
-				lErr = conn.PingContext(ctx)
 			}
+			pingErr := conn.PingContext(ctx)
+			if pingErr == nil {
+				continue
+			}
+			if opts.reacquire == nil {
+				lErr = pingErr
+				continue
+			}
+			newConn, reacErr := reacquireLock(ctx, db, conn, queries, args, opts.applicationName, *opts.reacquire)
+			if reacErr != nil {
+				lErr = reacErr
+				continue
+			}
+			conn = newConn
 		}
-		releaseErr := ignoreErr(releaseLock(conn, lockName))
+		releaseErr := ignoreErr(releaseLock(conn, queries, args))
 		if releaseErr != nil {
 			lErr = releaseErr
 		}
@@ -80,8 +508,69 @@ func Lock(ctx context.Context, db *sql.DB, lockName string, options ...LockOptio
 	return errs, nil
 }
 
-func lockID(lockName string) uint32 {
-	return crc32.ChecksumIEEE([]byte(lockName))
+// LockTx gets a transaction-scoped advisory lock from postgres. The lock is
+// held for the life of tx and is released automatically when tx commits or
+// rolls back; there is no ping goroutine and no release call. Valid modes are
+// ModeExclusiveTx (the default) and ModeSharedTx.
+func LockTx(ctx context.Context, tx *sql.Tx, lockName string, options ...LockOption) error {
+	opts := &lockOpts{}
+	for _, o := range options {
+		o(opts)
+	}
+	if !opts.modeSet {
+		opts.mode = ModeExclusiveTx
+	}
+	if opts.mode != ModeExclusiveTx && opts.mode != ModeSharedTx {
+		return fmt.Errorf("mode %v is not valid for LockTx, use Lock for session-scoped modes", opts.mode)
+	}
+
+	id := deriveKey(opts, lockName)
+	classid, objid := splitKey(id)
+	ok, err := getLockTx(ctx, tx, txModeQueries[opts.mode], id, opts.timeout)
+	if err != nil || !ok {
+		return fmt.Errorf("could not obtain lock: %w", lockFailureErr(ctx, tx, classid, objid, singleKeyObjSubID, err))
+	}
+	return nil
+}
+
+// lockFailureErr builds the error reported when a lock could not be obtained,
+// annotating it with whoever currently holds the lock when that information
+// is available.
+func lockFailureErr(ctx context.Context, q queryer, classid, objid, objsubid int32, err error) error {
+	if err == nil {
+		err = errors.New("lock is held by another session")
+	}
+	holders, hErr := lockHoldersByKey(ctx, q, classid, objid, objsubid)
+	if hErr != nil || len(holders) == 0 {
+		return err
+	}
+	holderStrs := make([]string, len(holders))
+	for i, h := range holders {
+		holderStrs[i] = h.String()
+	}
+	return fmt.Errorf("%w (held by %s)", err, strings.Join(holderStrs, ", "))
+}
+
+// defaultKeyDeriver hashes name into an advisory-lock key using 64-bit
+// FNV-1a, giving Lock access to the full advisory-lock keyspace. Override it
+// with WithKeyDeriver.
+func defaultKeyDeriver(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name)) //nolint:errcheck
+	return int64(h.Sum64())
+}
+
+func deriveKey(opts *lockOpts, name string) int64 {
+	if opts.keyDeriver != nil {
+		return opts.keyDeriver(name)
+	}
+	return defaultKeyDeriver(name)
+}
+
+// splitKey splits a 64-bit advisory-lock key into the classid/objid pair
+// postgres reports it under in pg_locks for the single-bigint lock form.
+func splitKey(id int64) (classid, objid int32) {
+	return int32(id >> 32), int32(id)
 }
 
 var ignoreableErrs = []error{
@@ -100,35 +589,196 @@ func ignoreErr(err error) error {
 	return err
 }
 
-func getLock(ctx context.Context, conn *sql.Conn, lockName string, timeout time.Duration) (bool, error) {
+func getLock(ctx context.Context, conn *sql.Conn, db *sql.DB, label string, classid, objid, objsubid int32, queries modeQueries, args []interface{}, opts *lockOpts) (bool, error) {
+	if opts.backoff != nil {
+		return pollForLock(ctx, conn, queries, args, opts)
+	}
+	if opts.timeout == 0 {
+		return tryLock(ctx, conn, queries, args...)
+	}
+	return waitForLock(ctx, conn, db, label, classid, objid, objsubid, queries, args, opts.timeout)
+}
+
+// pollForLock repeatedly calls pg_try_advisory_lock, waiting opts.backoff's
+// delay between attempts, until it succeeds, ctx is canceled, or
+// opts.timeout elapses. An opts.timeout of zero means keep polling until ctx
+// is canceled.
+func pollForLock(ctx context.Context, conn *sql.Conn, queries modeQueries, args []interface{}, opts *lockOpts) (bool, error) {
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		ok, err := tryLock(ctx, conn, queries, args...)
+		if err != nil || ok {
+			return ok, err
+		}
+		if opts.waitCallback != nil {
+			opts.waitCallback(attempt, time.Since(start))
+		}
+		timer := time.NewTimer(opts.backoff.Next(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reacquireLock is called when deadConn has failed a ping. It closes
+// deadConn, then opens new connections from db and tries to take the lock
+// described by queries/args on each, following policy's backoff until it
+// succeeds, ctx is canceled, or policy.Timeout elapses.
+func reacquireLock(ctx context.Context, db *sql.DB, deadConn *sql.Conn, queries modeQueries, args []interface{}, applicationName string, policy ReacquirePolicy) (*sql.Conn, error) {
+	_ = deadConn.Close() //nolint:errcheck
+	emitReacquireEvent(policy, EventLost)
+
+	backoff := policy.Backoff
+	if backoff == nil {
+		backoff = ConstantBackoff{Delay: time.Second}
+	}
+
+	parentCtx := ctx
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		emitReacquireEvent(policy, EventReacquiring)
+		conn, ok, err := tryReacquire(ctx, db, queries, args, applicationName)
+		if err == nil && ok {
+			emitReacquireEvent(policy, EventReacquired)
+			return conn, nil
+		}
+
+		timer := time.NewTimer(backoff.Next(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			emitReacquireEvent(policy, EventGaveUp)
+			// A canceled parent ctx is a normal shutdown; propagate its error
+			// as-is so the caller's ignoreErr treats it as clean. Anything
+			// else here means policy.Timeout expired while the parent ctx is
+			// still live, so the lock is gone and that must not be mistaken
+			// for a clean release.
+			if parentCtx.Err() != nil {
+				return nil, parentCtx.Err()
+			}
+			return nil, fmt.Errorf("gave up reacquiring lock: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func tryReacquire(ctx context.Context, db *sql.DB, queries modeQueries, args []interface{}, applicationName string) (*sql.Conn, bool, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if applicationName != "" {
+		if _, err = conn.ExecContext(ctx, "SELECT set_config('application_name', $1, false)", applicationName); err != nil {
+			_ = conn.Close() //nolint:errcheck
+			return nil, false, err
+		}
+	}
+	ok, err := tryLock(ctx, conn, queries, args...)
+	if err != nil || !ok {
+		_ = conn.Close() //nolint:errcheck
+		return nil, ok, err
+	}
+	return conn, true, nil
+}
+
+func emitReacquireEvent(policy ReacquirePolicy, event ReacquireEvent) {
+	if policy.OnEvent != nil {
+		policy.OnEvent(event)
+	}
+}
+
+func tryLock(ctx context.Context, conn *sql.Conn, queries modeQueries, args ...interface{}) (bool, error) {
+	var ok bool
+	err := conn.QueryRowContext(ctx, queries.tryLock, args...).Scan(&ok)
+	if err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func waitForLock(ctx context.Context, conn *sql.Conn, db *sql.DB, label string, classid, objid, objsubid int32, queries modeQueries, args []interface{}, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go logHoldersPeriodically(ctx, db, label, classid, objid, objsubid, done)
+
+	_, err := conn.ExecContext(ctx, queries.blockingLock, args...)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func getLockTx(ctx context.Context, tx *sql.Tx, queries modeQueries, id int64, timeout time.Duration) (bool, error) {
 	if timeout == 0 {
-		return tryLock(ctx, conn, lockName)
+		return tryLockTx(ctx, tx, queries, id)
 	}
-	return waitForLock(ctx, conn, lockName, timeout)
+	return waitForLockTx(ctx, tx, queries, id, timeout)
 }
 
-func tryLock(ctx context.Context, conn *sql.Conn, lockName string) (bool, error) {
+func tryLockTx(ctx context.Context, tx *sql.Tx, queries modeQueries, id int64) (bool, error) {
 	var ok bool
-	err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockID(lockName)).Scan(&ok)
+	err := tx.QueryRowContext(ctx, queries.tryLock, id).Scan(&ok)
 	if err != nil {
 		return false, err
 	}
 	return ok, nil
 }
 
-func waitForLock(ctx context.Context, conn *sql.Conn, lockName string, timeout time.Duration) (bool, error) {
+func waitForLockTx(ctx context.Context, tx *sql.Tx, queries modeQueries, id int64, timeout time.Duration) (bool, error) {
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
-	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID(lockName))
+	_, err := tx.ExecContext(ctx, queries.blockingLock, id)
 	if err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func releaseLock(conn *sql.Conn, lockName string) error {
+// logHoldersPeriodically logs who holds the lock described by label every
+// holderLogInterval, until ctx is done or done is closed. It's used by
+// waitForLock so a caller blocked on a contended lock gets periodic "who has
+// it?" diagnostics instead of silence.
+func logHoldersPeriodically(ctx context.Context, db *sql.DB, label string, classid, objid, objsubid int32, done <-chan struct{}) {
+	ticker := time.NewTicker(holderLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			holders, err := lockHoldersByKey(ctx, db, classid, objid, objsubid)
+			if err != nil {
+				continue
+			}
+			for _, h := range holders {
+				log.Printf("pglocker: still waiting for lock %s, held by %s", label, h)
+			}
+		}
+	}
+}
+
+func releaseLock(conn *sql.Conn, queries modeQueries, args []interface{}) error {
 	// use our own context so we can attempt to release a lock even after the calling function's context has been closed
 	ctx := context.Background()
-	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockID(lockName))
+	_, err := conn.ExecContext(ctx, queries.unlock, args...)
 	return err
 }
@@ -122,6 +122,116 @@ func TestLock(t *testing.T) {
 		require.Greater(t, int64(delta), int64(timeout))
 	})
 
+	t.Run("polls with a backoff strategy", func(t *testing.T) {
+		t.Parallel()
+		lockName := t.Name()
+		db := getDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// The holder releases the lock when holderCtx expires, while the
+		// second Lock is still polling.
+		holderCtx, holderCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer holderCancel()
+		_, err := Lock(holderCtx, db, lockName)
+		require.NoError(t, err)
+
+		var attempts int
+		var mu sync.Mutex
+		errs, err := Lock(ctx, db, lockName,
+			WithTimeout(time.Second),
+			WithBackoff(ConstantBackoff{Delay: 10 * time.Millisecond}),
+			WithWaitCallback(func(attempt int, elapsed time.Duration) {
+				mu.Lock()
+				attempts++
+				mu.Unlock()
+			}),
+		)
+		require.NoError(t, err)
+		require.NotNil(t, errs)
+		mu.Lock()
+		require.Greater(t, attempts, 0)
+		mu.Unlock()
+		cancel()
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("polls with a backoff strategy and no timeout", func(t *testing.T) {
+		t.Parallel()
+		lockName := t.Name()
+		db := getDB(t)
+		holderCtx, holderCancel := context.WithCancel(context.Background())
+		holderErrs, err := Lock(holderCtx, db, lockName)
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			holderCancel()
+		}()
+
+		var attempts int
+		var mu sync.Mutex
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// No WithTimeout: WithBackoff alone must still poll, bounded only by
+		// ctx, instead of giving up after a single attempt.
+		errs, err := Lock(ctx, db, lockName,
+			WithBackoff(ConstantBackoff{Delay: 10 * time.Millisecond}),
+			WithWaitCallback(func(attempt int, elapsed time.Duration) {
+				mu.Lock()
+				attempts++
+				mu.Unlock()
+			}),
+		)
+		require.NoError(t, err)
+		require.NotNil(t, errs)
+		mu.Lock()
+		require.Greater(t, attempts, 0)
+		mu.Unlock()
+
+		require.NoError(t, <-holderErrs)
+		cancel()
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("reports holders of a contended lock", func(t *testing.T) {
+		t.Parallel()
+		lockName := t.Name()
+		db := getDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err := Lock(ctx, db, lockName, WithApplicationName("pglocker-test-holder"))
+		require.NoError(t, err)
+
+		errs, err := Lock(ctx, db, lockName)
+		require.Error(t, err)
+		require.Nil(t, errs)
+		require.Contains(t, err.Error(), "pglocker-test-holder")
+
+		holders, err := LockHolders(ctx, db, lockName)
+		require.NoError(t, err)
+		require.Len(t, holders, 1)
+		require.Equal(t, "pglocker-test-holder", holders[0].ApplicationName)
+	})
+
+	t.Run("shared locks can be held concurrently", func(t *testing.T) {
+		t.Parallel()
+		lockName := t.Name()
+		db := getDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errs1, err := Lock(ctx, db, lockName, WithMode(ModeShared))
+		require.NoError(t, err)
+		errs2, err := Lock(ctx, db, lockName, WithMode(ModeShared))
+		require.NoError(t, err)
+
+		_, err = Lock(ctx, db, lockName)
+		require.Error(t, err)
+
+		cancel()
+		require.NoError(t, <-errs1)
+		require.NoError(t, <-errs2)
+	})
+
 	t.Run("release and relock", func(t *testing.T) {
 		t.Parallel()
 		lockName := t.Name()
@@ -142,3 +252,183 @@ func TestLock(t *testing.T) {
 		require.NoError(t, <-errs)
 	})
 }
+
+func TestLockTx(t *testing.T) {
+	t.Run("locks until commit", func(t *testing.T) {
+		t.Parallel()
+		lockName := t.Name()
+		db := getDB(t)
+		bgCtx := context.Background()
+		tx, err := db.BeginTx(bgCtx, nil)
+		require.NoError(t, err)
+		require.NoError(t, LockTx(bgCtx, tx, lockName))
+
+		_, err = Lock(bgCtx, db, lockName)
+		require.Error(t, err)
+
+		require.NoError(t, tx.Commit())
+
+		ctx, cancel := context.WithCancel(bgCtx)
+		defer cancel()
+		errs, err := Lock(ctx, db, lockName)
+		require.NoError(t, err)
+		cancel()
+		require.NoError(t, <-errs)
+	})
+
+	t.Run("rejects session-scoped modes", func(t *testing.T) {
+		t.Parallel()
+		lockName := t.Name()
+		db := getDB(t)
+		ctx := context.Background()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, tx.Rollback()) }()
+		err = LockTx(ctx, tx, lockName, WithMode(ModeShared))
+		require.Error(t, err)
+	})
+}
+
+func TestLockByID(t *testing.T) {
+	t.Parallel()
+	db := getDB(t)
+	const id int64 = 1234567890123
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs, err := Lock(ctx, db, "TestLockByID", WithKeyDeriver(func(string) int64 { return id }))
+	require.NoError(t, err)
+
+	_, err = LockByID(ctx, db, id)
+	require.Error(t, err)
+
+	holders, err := LockHoldersByID(ctx, db, id)
+	require.NoError(t, err)
+	require.Len(t, holders, 1)
+
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestLockByKeys(t *testing.T) {
+	t.Parallel()
+	db := getDB(t)
+	var key1, key2 int32 = 42, 7
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs, err := LockByKeys(ctx, db, key1, key2)
+	require.NoError(t, err)
+
+	_, err = LockByKeys(ctx, db, key1, key2)
+	require.Error(t, err)
+
+	holders, err := LockHoldersByKeys(ctx, db, key1, key2)
+	require.NoError(t, err)
+	require.Len(t, holders, 1)
+
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestWithReacquire(t *testing.T) {
+	t.Parallel()
+	lockName := t.Name()
+	db := getDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		events []ReacquireEvent
+	)
+	recordEvent := func(e ReacquireEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+
+	errs, err := Lock(ctx, db, lockName,
+		WithPingInterval(10*time.Millisecond),
+		WithReacquire(ReacquirePolicy{
+			Backoff: ConstantBackoff{Delay: 10 * time.Millisecond},
+			OnEvent: recordEvent,
+		}),
+	)
+	require.NoError(t, err)
+
+	holders, err := LockHolders(ctx, db, lockName)
+	require.NoError(t, err)
+	require.Len(t, holders, 1)
+	_, err = db.ExecContext(ctx, "SELECT pg_terminate_backend($1)", holders[0].PID)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, e := range events {
+			if e == EventReacquired {
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, err = Lock(ctx, db, lockName)
+	require.Error(t, err, "lock should still be held after reacquiring")
+
+	mu.Lock()
+	require.Contains(t, events, EventLost)
+	require.Contains(t, events, EventReacquired)
+	mu.Unlock()
+
+	cancel()
+	require.NoError(t, <-errs)
+}
+
+func TestWithReacquireGivesUp(t *testing.T) {
+	t.Parallel()
+	lockName := t.Name()
+	db := getDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		events []ReacquireEvent
+	)
+	recordEvent := func(e ReacquireEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+
+	errs, err := Lock(ctx, db, lockName,
+		WithPingInterval(10*time.Millisecond),
+		WithReacquire(ReacquirePolicy{
+			Backoff: ConstantBackoff{Delay: time.Millisecond},
+			Timeout: time.Nanosecond,
+			OnEvent: recordEvent,
+		}),
+	)
+	require.NoError(t, err)
+
+	holders, err := LockHolders(ctx, db, lockName)
+	require.NoError(t, err)
+	require.Len(t, holders, 1)
+	_, err = db.ExecContext(ctx, "SELECT pg_terminate_backend($1)", holders[0].PID)
+	require.NoError(t, err)
+
+	// policy.Timeout is effectively already expired, so reacquisition gives
+	// up on its first attempt. errs must deliver a real error here, not nil
+	// as if the lock had been released cleanly.
+	err = <-errs
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "gave up reacquiring lock")
+
+	mu.Lock()
+	require.Contains(t, events, EventLost)
+	require.Contains(t, events, EventGaveUp)
+	require.NotContains(t, events, EventReacquired)
+	mu.Unlock()
+}